@@ -0,0 +1,213 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"k8s.io/klog/v2"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ Backend = &boltBackend{}
+
+// boltFileSuffix is appended to the checkpoint name to derive the path of
+// the BoltDB file used by BackendTypeBolt.
+const boltFileSuffix = ".bolt"
+
+// boltOpenTimeout bounds how long a read-only bbolt.Open waits to acquire its
+// shared file lock before giving up, so a read-only caller (e.g. Export)
+// fails fast instead of hanging if another process is mid-write.
+const boltOpenTimeout = 5 * time.Second
+
+// podAllocationBucket holds one key per pod UID, with the value being the
+// JSON-encoded map of that pod's container resource allocations.
+var podAllocationBucket = []byte("podResourceAllocation")
+
+// boltBackend keeps each pod's allocation in its own BoltDB key, so
+// StorePod/DeletePod are single-key writes instead of rewriting every pod's
+// allocation, unlike fileBackend.
+type boltBackend struct {
+	db *bbolt.DB
+}
+
+func newBoltBackend(dbPath string) (*boltBackend, error) {
+	db, err := bbolt.Open(dbPath, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pod resource allocation bolt db %q: %w", dbPath, err)
+	}
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(podAllocationBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize pod resource allocation bolt db %q: %w", dbPath, err)
+	}
+	return &boltBackend{db: db}, nil
+}
+
+// newBoltBackendWithMigration opens the Bolt backend for checkpointName
+// under stateDir and, if the Bolt DB is empty and a legacy file-based
+// checkpoint exists, imports it. This lets an operator flip
+// --pod-resource-allocation-backend from file to bolt without losing
+// allocations already recorded on the node.
+func newBoltBackendWithMigration(stateDir, checkpointName string) (Backend, error) {
+	dbPath := path.Join(stateDir, checkpointName+boltFileSuffix)
+	backend, err := newBoltBackend(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := backend.Load()
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return backend, nil
+	}
+
+	legacy, err := newFileBackend(stateDir, checkpointName, WALOptions{}, func() PodResourceAllocation { return nil })
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	defer legacy.Close()
+
+	pra, err := legacy.Load()
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	if len(pra) == 0 {
+		return backend, nil
+	}
+
+	klog.InfoS("State checkpoint: migrating pod resource allocation checkpoint to bolt backend", "pods", len(pra))
+	if err := backend.StoreAll(pra); err != nil {
+		backend.Close()
+		return nil, fmt.Errorf("failed to migrate pod resource allocation checkpoint to bolt backend: %w", err)
+	}
+	return backend, nil
+}
+
+// loadBoltAllocationReadOnly returns the pod resource allocation persisted by
+// BackendTypeBolt under stateDir/checkpointName. It opens the BoltDB file
+// read-only, so it can run alongside a live kubelet holding the same file's
+// write lock (e.g. from the Export CLI entrypoint) instead of blocking on it
+// forever.
+func loadBoltAllocationReadOnly(stateDir, checkpointName string) (PodResourceAllocation, error) {
+	dbPath := path.Join(stateDir, checkpointName+boltFileSuffix)
+	db, err := bbolt.Open(dbPath, 0600, &bbolt.Options{ReadOnly: true, Timeout: boltOpenTimeout})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open pod resource allocation bolt db %q: %w", dbPath, err)
+	}
+	defer db.Close()
+
+	b := &boltBackend{db: db}
+	return b.Load()
+}
+
+func (b *boltBackend) Load() (PodResourceAllocation, error) {
+	pra := PodResourceAllocation{}
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(podAllocationBucket)
+		if bucket == nil {
+			// A bucket won't exist yet if this db was just opened read-only
+			// before anything was ever written to it.
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var containers map[string]v1.ResourceRequirements
+			if err := json.Unmarshal(v, &containers); err != nil {
+				return fmt.Errorf("failed to unmarshal pod resource allocation for pod %q: %w", string(k), err)
+			}
+			pra[types.UID(k)] = containers
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pra) == 0 {
+		return nil, nil
+	}
+	return pra, nil
+}
+
+func (b *boltBackend) StorePod(podUID types.UID, containers map[string]v1.ResourceRequirements) error {
+	data, err := json.Marshal(containers)
+	if err != nil {
+		return fmt.Errorf("failed to marshal pod resource allocation for pod %q: %w", podUID, err)
+	}
+	if err := b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(podAllocationBucket).Put([]byte(podUID), data)
+	}); err != nil {
+		return fmt.Errorf("failed to store pod resource allocation for pod %q: %w", podUID, err)
+	}
+	return nil
+}
+
+func (b *boltBackend) DeletePod(podUID types.UID) error {
+	if err := b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(podAllocationBucket).Delete([]byte(podUID))
+	}); err != nil {
+		return fmt.Errorf("failed to delete pod resource allocation for pod %q: %w", podUID, err)
+	}
+	return nil
+}
+
+func (b *boltBackend) StoreAll(pra PodResourceAllocation) error {
+	if err := b.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.DeleteBucket(podAllocationBucket); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucket(podAllocationBucket)
+		if err != nil {
+			return err
+		}
+		for podUID, containers := range pra {
+			data, err := json.Marshal(containers)
+			if err != nil {
+				return fmt.Errorf("failed to marshal pod resource allocation for pod %q: %w", podUID, err)
+			}
+			if err := bucket.Put([]byte(podUID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to store pod resource allocation: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying BoltDB file, releasing its flock. It is safe
+// to call more than once.
+func (b *boltBackend) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestBoltBackendStorePodAndDeletePod(t *testing.T) {
+	dbPath := path.Join(t.TempDir(), "allocation-checkpoint"+boltFileSuffix)
+	b, err := newBoltBackend(dbPath)
+	require.NoError(t, err)
+	defer b.Close()
+
+	podUID, alloc := testAllocation()
+	require.NoError(t, b.StorePod(podUID, alloc))
+
+	pra, err := b.Load()
+	require.NoError(t, err)
+	assert.Equal(t, alloc, pra[podUID])
+
+	require.NoError(t, b.DeletePod(podUID))
+	pra, err = b.Load()
+	require.NoError(t, err)
+	assert.NotContains(t, pra, podUID)
+}
+
+func TestBoltBackendStoreAllReplacesContents(t *testing.T) {
+	dbPath := path.Join(t.TempDir(), "allocation-checkpoint"+boltFileSuffix)
+	b, err := newBoltBackend(dbPath)
+	require.NoError(t, err)
+	defer b.Close()
+
+	podUID, alloc := testAllocation()
+	require.NoError(t, b.StorePod(podUID, alloc))
+
+	_, otherAlloc := testAllocation()
+	otherUID := types.UID("pod-2")
+	require.NoError(t, b.StoreAll(PodResourceAllocation{otherUID: otherAlloc}))
+
+	pra, err := b.Load()
+	require.NoError(t, err)
+	assert.NotContains(t, pra, podUID)
+	assert.Equal(t, otherAlloc, pra[otherUID])
+}
+
+func TestLoadBoltAllocationReadOnlyMissingFile(t *testing.T) {
+	pra, err := loadBoltAllocationReadOnly(t.TempDir(), "allocation-checkpoint")
+	require.NoError(t, err)
+	assert.Nil(t, pra)
+}
+
+func TestNewBoltBackendWithMigrationImportsLegacyFileCheckpoint(t *testing.T) {
+	stateDir := t.TempDir()
+	podUID, alloc := testAllocation()
+
+	legacy, err := newFileBackend(stateDir, "allocation-checkpoint", WALOptions{}, func() PodResourceAllocation { return nil })
+	require.NoError(t, err)
+	require.NoError(t, legacy.StorePod(podUID, alloc))
+	require.NoError(t, legacy.Close())
+
+	backend, err := newBoltBackendWithMigration(stateDir, "allocation-checkpoint")
+	require.NoError(t, err)
+	defer backend.Close()
+
+	pra, err := backend.Load()
+	require.NoError(t, err)
+	assert.Equal(t, alloc, pra[podUID])
+}
+
+func TestNewBoltBackendWithMigrationSkipsWhenBoltAlreadyHasData(t *testing.T) {
+	stateDir := t.TempDir()
+	podUID, alloc := testAllocation()
+
+	dbPath := path.Join(stateDir, "allocation-checkpoint"+boltFileSuffix)
+	seed, err := newBoltBackend(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, seed.StorePod(podUID, alloc))
+	require.NoError(t, seed.Close())
+
+	_, legacyAlloc := testAllocation()
+	legacyUID := types.UID("pod-legacy")
+	legacy, err := newFileBackend(stateDir, "allocation-checkpoint", WALOptions{}, func() PodResourceAllocation { return nil })
+	require.NoError(t, err)
+	require.NoError(t, legacy.StorePod(legacyUID, legacyAlloc))
+	require.NoError(t, legacy.Close())
+
+	backend, err := newBoltBackendWithMigration(stateDir, "allocation-checkpoint")
+	require.NoError(t, err)
+	defer backend.Close()
+
+	pra, err := backend.Load()
+	require.NoError(t, err)
+	assert.Equal(t, alloc, pra[podUID])
+	assert.NotContains(t, pra, legacyUID, "migration should not run once the bolt db already has data")
+}
+
+// TestNewBoltBackendWithMigrationClosesLegacyFileBackend is a regression test
+// for the legacy fileBackend's WAL handle leaking on every call to
+// newBoltBackendWithMigration that actually migrates data: it opens its own
+// fileBackend purely to read the old checkpoint, and used to never close it.
+func TestNewBoltBackendWithMigrationClosesLegacyFileBackend(t *testing.T) {
+	stateDir := t.TempDir()
+	podUID, alloc := testAllocation()
+
+	legacy, err := newFileBackend(stateDir, "allocation-checkpoint", WALOptions{}, func() PodResourceAllocation { return nil })
+	require.NoError(t, err)
+	require.NoError(t, legacy.StorePod(podUID, alloc))
+	require.NoError(t, legacy.Close())
+
+	before := countOpenFDs(t)
+
+	backend, err := newBoltBackendWithMigration(stateDir, "allocation-checkpoint")
+	require.NoError(t, err)
+	require.NoError(t, backend.Close())
+
+	after := countOpenFDs(t)
+	assert.Equal(t, before, after, "newBoltBackendWithMigration must not leak the legacy file backend's WAL handle")
+}
+
+// countOpenFDs returns the number of open file descriptors for this process,
+// skipping the test if that can't be determined on this platform.
+func countOpenFDs(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("cannot enumerate open file descriptors on this platform: %v", err)
+	}
+	return len(entries)
+}
@@ -0,0 +1,485 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
+	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager/checksum"
+	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager/errors"
+)
+
+// walCorruptionDetected counts WAL replay failures that were NOT a clean
+// truncated tail record (the one corruption mode replayWAL is meant to
+// tolerate silently). Every increment means replay stopped partway through
+// the journal and discarded already-fsynced records that came after the
+// corrupt one.
+var walCorruptionDetected = metrics.NewCounter(&metrics.CounterOpts{
+	Subsystem:      "node_allocation",
+	Name:           "wal_corruption_detected_total",
+	Help:           "Number of times replaying the pod resource allocation WAL found a corrupt record with further records still following it in the journal.",
+	StabilityLevel: metrics.ALPHA,
+})
+
+func init() {
+	legacyregistry.MustRegister(walCorruptionDetected)
+}
+
+var _ Backend = &fileBackend{}
+
+// walFileSuffix is appended to the checkpoint name to derive the path of the
+// delta journal that backs incremental writes between full checkpoint rewrites.
+const walFileSuffix = ".wal"
+
+const (
+	// defaultWALMaxBytes bounds how large the delta journal is allowed to grow
+	// before it is compacted into the main checkpoint.
+	defaultWALMaxBytes = 1 * 1024 * 1024
+	// defaultWALMaxEntries bounds how many records the delta journal is allowed
+	// to accumulate before it is compacted into the main checkpoint.
+	defaultWALMaxEntries = 1024
+)
+
+// WALOptions configures how large the delta journal is allowed to grow
+// before fileBackend compacts it into the main checkpoint file. The zero
+// value selects defaultWALMaxBytes/defaultWALMaxEntries, so callers that
+// don't need to tune this can pass WALOptions{}.
+type WALOptions struct {
+	MaxBytes   int64
+	MaxEntries int
+}
+
+// withDefaults returns o with any unset field filled in from the package
+// defaults.
+func (o WALOptions) withDefaults() WALOptions {
+	if o.MaxBytes <= 0 {
+		o.MaxBytes = defaultWALMaxBytes
+	}
+	if o.MaxEntries <= 0 {
+		o.MaxEntries = defaultWALMaxEntries
+	}
+	return o
+}
+
+// maxWALRecordBytes bounds the payload length readWALRecord will ever trust
+// out of a record's untrusted length prefix. Without a cap, a single flipped
+// bit in that prefix turns a normal truncated/corrupt record into an
+// allocation request of up to 4GiB, which can OOM the node during
+// restoreWAL() at kubelet startup instead of just dropping the record.
+const maxWALRecordBytes = 8 * 1024 * 1024
+
+// walOp identifies the operation recorded by a single WAL record.
+type walOp byte
+
+const (
+	walOpSet walOp = iota + 1
+	walOpDelete
+)
+
+// walRecord is a single entry in the delta journal. Each one records a
+// pod's complete container allocation (for walOpSet) or its removal (for
+// walOpDelete), so replaying the journal in order reproduces every StorePod
+// and DeletePod call made since the last compaction.
+type walRecord struct {
+	Op         walOp
+	PodUID     types.UID
+	Containers map[string]v1.ResourceRequirements
+}
+
+// fileBackend is the original checkpoint backend: the full allocation is
+// kept as a single JSON file written through checkpointmanager. Because
+// rewriting that file on every mutation is O(N) in the number of tracked
+// containers, mutations are instead appended to a small append-only WAL
+// sidecar and only folded into the checkpoint file once the WAL passes
+// walMaxBytes/walMaxEntries.
+type fileBackend struct {
+	checkpointManager checkpointmanager.CheckpointManager
+	checkpointName    string
+	lastChecksum      checksum.Checksum
+	snapshot          func() PodResourceAllocation
+
+	mux sync.Mutex
+
+	walPath       string
+	walFile       *os.File
+	walMaxBytes   int64
+	walMaxEntries int
+	walBytes      int64
+	walEntries    int
+}
+
+// newFileBackend opens (creating if necessary) the JSON checkpoint and its
+// WAL sidecar under stateDir, replaying any journal left over from a
+// previous run. snapshot is called at compaction time to get the complete
+// current allocation, since the WAL only ever holds the pods that changed.
+// walOptions configures the compaction threshold; its zero value uses the
+// package defaults.
+func newFileBackend(stateDir, checkpointName string, walOptions WALOptions, snapshot func() PodResourceAllocation) (*fileBackend, error) {
+	checkpointManager, err := checkpointmanager.NewCheckpointManager(stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize checkpoint manager for pod allocation tracking: %v", err)
+	}
+
+	walOptions = walOptions.withDefaults()
+	b := &fileBackend{
+		checkpointManager: checkpointManager,
+		checkpointName:    checkpointName,
+		snapshot:          snapshot,
+		walPath:           path.Join(stateDir, checkpointName+walFileSuffix),
+		walMaxBytes:       walOptions.MaxBytes,
+		walMaxEntries:     walOptions.MaxEntries,
+	}
+
+	if err := b.restoreWAL(); err != nil {
+		return nil, fmt.Errorf("failed to restore pod resource allocation WAL, please drain this node and delete pod allocation checkpoint files under %q before restarting Kubelet: %w",
+			stateDir, err)
+	}
+
+	return b, nil
+}
+
+// Load restores the checkpoint written by a previous run, replaying its WAL
+// on top.
+func (b *fileBackend) Load() (PodResourceAllocation, error) {
+	checkpoint := &Checkpoint{}
+	if err := b.checkpointManager.GetCheckpoint(b.checkpointName, checkpoint); err != nil {
+		if err == errors.ErrCheckpointNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	praInfo, err := checkpoint.GetPodResourceAllocationInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod resource allocation info: %w", err)
+	}
+
+	b.lastChecksum = checkpoint.Checksum
+	klog.V(2).InfoS("State checkpoint: restored pod resource allocation state from checkpoint")
+	return praInfo.AllocationEntries, nil
+}
+
+// restoreWAL replays any delta journal left over from a previous run onto
+// whatever Load will later return, tolerating a truncated tail record left
+// by a crash mid-write. It always leaves behind a fresh, empty, open journal.
+//
+// This mutates the on-disk WAL (it removes and recreates it via resetWAL) and
+// must only run against a backend this process owns exclusively. Read-only
+// consumers (e.g. ExportCheckpoint) must use loadFileAllocationReadOnly
+// instead, which replays the same records without touching disk.
+func (b *fileBackend) restoreWAL() error {
+	pra, err := b.Load()
+	if err != nil {
+		return err
+	}
+
+	pra, replayed, err := replayWAL(b.walPath, pra)
+	if err != nil {
+		return err
+	}
+
+	if replayed > 0 {
+		klog.V(2).InfoS("State checkpoint: replayed pod resource allocation WAL records", "count", replayed)
+		if err := b.storeAllLocked(pra); err != nil {
+			return err
+		}
+	}
+	return b.resetWAL()
+}
+
+// replayWAL applies every well-formed record in the journal at walPath on top
+// of pra and returns the result along with how many records were replayed. A
+// missing journal is not an error. A truncated trailing record (left by a
+// crash mid-append) stops replay without failing it. A corrupt record with
+// more of the journal still following it is a different, louder condition:
+// it means replay is discarding already-fsynced records, not just an
+// incomplete final write, so it's logged as an error and counted in
+// walCorruptionDetected rather than silently ignored. replayWAL only reads
+// walPath; it never creates, truncates, or removes it.
+func replayWAL(walPath string, pra PodResourceAllocation) (PodResourceAllocation, int, error) {
+	f, openErr := os.Open(walPath)
+	if openErr != nil {
+		if os.IsNotExist(openErr) {
+			return pra, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to open pod resource allocation WAL %q: %w", walPath, openErr)
+	}
+	defer f.Close()
+
+	replayed := 0
+	for {
+		rec, err := readWALRecord(f)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if atEOF, statErr := atEndOfFile(f); statErr == nil && atEOF {
+				klog.InfoS("State checkpoint: ignoring truncated pod resource allocation WAL tail record", "err", err)
+			} else {
+				klog.ErrorS(err, "State checkpoint: pod resource allocation WAL record is corrupt with further records still following it in the journal; discarding the rest of the journal")
+				walCorruptionDetected.Inc()
+			}
+			break
+		}
+		pra = applyWALRecord(pra, rec)
+		replayed++
+	}
+	return pra, replayed, nil
+}
+
+// atEndOfFile reports whether f's current read offset is at (or past) the
+// end of the file, i.e. whether the read that just failed consumed the last
+// bytes in the journal rather than stopping partway through it.
+func atEndOfFile(f *os.File) (bool, error) {
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	return pos >= info.Size(), nil
+}
+
+// loadFileAllocationReadOnly returns the pod resource allocation persisted by
+// BackendTypeFile under stateDir/checkpointName, replaying its WAL sidecar in
+// memory. Unlike newFileBackend, it never creates, rewrites, or removes any
+// file on disk, so it's safe to call against a checkpoint a live kubelet
+// still owns (e.g. from the Export CLI entrypoint).
+func loadFileAllocationReadOnly(stateDir, checkpointName string) (PodResourceAllocation, error) {
+	checkpointManager, err := checkpointmanager.NewCheckpointManager(stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize checkpoint manager for pod allocation tracking: %v", err)
+	}
+
+	checkpoint := &Checkpoint{}
+	var pra PodResourceAllocation
+	if err := checkpointManager.GetCheckpoint(checkpointName, checkpoint); err != nil {
+		if err != errors.ErrCheckpointNotFound {
+			return nil, err
+		}
+	} else {
+		praInfo, err := checkpoint.GetPodResourceAllocationInfo()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pod resource allocation info: %w", err)
+		}
+		pra = praInfo.AllocationEntries
+	}
+
+	walPath := path.Join(stateDir, checkpointName+walFileSuffix)
+	pra, _, err = replayWAL(walPath, pra)
+	if err != nil {
+		return nil, err
+	}
+	return pra, nil
+}
+
+func applyWALRecord(pra PodResourceAllocation, rec walRecord) PodResourceAllocation {
+	if pra == nil {
+		pra = PodResourceAllocation{}
+	}
+	switch rec.Op {
+	case walOpSet:
+		pra[rec.PodUID] = rec.Containers
+	case walOpDelete:
+		delete(pra, rec.PodUID)
+	}
+	return pra
+}
+
+// openWAL opens (creating if necessary) the journal file for appending.
+func (b *fileBackend) openWAL() error {
+	f, err := os.OpenFile(b.walPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open pod resource allocation WAL %q: %w", b.walPath, err)
+	}
+	b.walFile = f
+	return nil
+}
+
+// resetWAL discards the current journal file and reopens an empty one.
+func (b *fileBackend) resetWAL() error {
+	if b.walFile != nil {
+		b.walFile.Close()
+		b.walFile = nil
+	}
+	if err := os.Remove(b.walPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove pod resource allocation WAL %q: %w", b.walPath, err)
+	}
+	b.walBytes = 0
+	b.walEntries = 0
+	return b.openWAL()
+}
+
+// StorePod appends a record of podUID's new allocation to the WAL,
+// compacting into the main checkpoint once the journal grows past the
+// configured size or entry count.
+func (b *fileBackend) StorePod(podUID types.UID, containers map[string]v1.ResourceRequirements) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.appendWAL(walRecord{Op: walOpSet, PodUID: podUID, Containers: containers})
+}
+
+// DeletePod appends a deletion record for podUID to the WAL.
+func (b *fileBackend) DeletePod(podUID types.UID) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.appendWAL(walRecord{Op: walOpDelete, PodUID: podUID})
+}
+
+func (b *fileBackend) appendWAL(rec walRecord) error {
+	if b.walFile == nil {
+		// No journal available (e.g. it failed to reopen after a previous
+		// compaction); fall back to the safe, if more expensive, full rewrite.
+		return b.storeAllLocked(b.snapshot())
+	}
+
+	n, err := writeWALRecord(b.walFile, rec)
+	if err != nil {
+		klog.ErrorS(err, "Failed to append pod resource allocation WAL record, falling back to full checkpoint rewrite")
+		return b.storeAllLocked(b.snapshot())
+	}
+	b.walBytes += int64(n)
+	b.walEntries++
+
+	if b.walBytes >= b.walMaxBytes || b.walEntries >= b.walMaxEntries {
+		if err := b.storeAllLocked(b.snapshot()); err != nil {
+			return err
+		}
+		return b.resetWAL()
+	}
+	return nil
+}
+
+// StoreAll rewrites the checkpoint file from scratch and discards the WAL.
+func (b *fileBackend) StoreAll(pra PodResourceAllocation) error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if err := b.storeAllLocked(pra); err != nil {
+		return err
+	}
+	return b.resetWAL()
+}
+
+// storeAllLocked saves pra to the checkpoint file, caller is responsible for locking.
+func (b *fileBackend) storeAllLocked(pra PodResourceAllocation) error {
+	checkpoint, err := NewCheckpoint(&PodResourceAllocationInfo{
+		AllocationEntries: pra,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+	if checkpoint.Checksum == b.lastChecksum {
+		// No changes to the checkpoint => no need to re-write it.
+		return nil
+	}
+	if err := b.checkpointManager.CreateCheckpoint(b.checkpointName, checkpoint); err != nil {
+		klog.ErrorS(err, "Failed to save pod allocation checkpoint")
+		return err
+	}
+	b.lastChecksum = checkpoint.Checksum
+	return nil
+}
+
+// Close closes the open WAL file handle. It is safe to call more than once.
+func (b *fileBackend) Close() error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.walFile == nil {
+		return nil
+	}
+	err := b.walFile.Close()
+	b.walFile = nil
+	return err
+}
+
+// writeWALRecord appends a length-prefixed, CRC-protected record to w and
+// returns the number of bytes written.
+func writeWALRecord(w io.Writer, rec walRecord) (int, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal WAL record: %w", err)
+	}
+
+	buf := make([]byte, 4+len(payload)+4)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	copy(buf[4:4+len(payload)], payload)
+	binary.BigEndian.PutUint32(buf[4+len(payload):], crc32.ChecksumIEEE(payload))
+
+	n, err := w.Write(buf)
+	if err != nil {
+		return n, err
+	}
+	if f, ok := w.(*os.File); ok {
+		if err := f.Sync(); err != nil {
+			return n, fmt.Errorf("failed to sync WAL record: %w", err)
+		}
+	}
+	return n, nil
+}
+
+// readWALRecord reads a single record written by writeWALRecord. It returns
+// io.EOF when the journal is exhausted, and a non-EOF error when the trailing
+// record is truncated or fails its checksum, so the caller can stop replay
+// without treating the rest of the journal as fatal.
+func readWALRecord(r io.Reader) (walRecord, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return walRecord{}, fmt.Errorf("truncated WAL record length: %w", err)
+		}
+		return walRecord{}, err
+	}
+
+	payloadLen := binary.BigEndian.Uint32(lenBuf[:])
+	if payloadLen > maxWALRecordBytes {
+		return walRecord{}, fmt.Errorf("WAL record length %d exceeds maximum of %d, treating as corrupt", payloadLen, maxWALRecordBytes)
+	}
+
+	payload := make([]byte, payloadLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return walRecord{}, fmt.Errorf("truncated WAL record payload: %w", err)
+	}
+
+	var crcBuf [4]byte
+	if _, err := io.ReadFull(r, crcBuf[:]); err != nil {
+		return walRecord{}, fmt.Errorf("truncated WAL record checksum: %w", err)
+	}
+	if want, got := binary.BigEndian.Uint32(crcBuf[:]), crc32.ChecksumIEEE(payload); want != got {
+		return walRecord{}, fmt.Errorf("WAL record checksum mismatch: got %d, want %d", got, want)
+	}
+
+	var rec walRecord
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return walRecord{}, fmt.Errorf("failed to unmarshal WAL record: %w", err)
+	}
+	return rec, nil
+}
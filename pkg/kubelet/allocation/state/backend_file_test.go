@@ -0,0 +1,144 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTestFileBackend(t *testing.T, walOptions WALOptions) *fileBackend {
+	t.Helper()
+	stateDir := t.TempDir()
+	b, err := newFileBackend(stateDir, "allocation-checkpoint", walOptions, func() PodResourceAllocation { return nil })
+	require.NoError(t, err)
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+func TestFileBackendStorePodAppendsToWALUntilCompacted(t *testing.T) {
+	podUID, alloc := testAllocation()
+	b := newTestFileBackend(t, WALOptions{MaxEntries: 2})
+
+	require.NoError(t, b.StorePod(podUID, alloc))
+	assert.Equal(t, 1, b.walEntries, "first StorePod should land in the WAL, not trigger a compaction")
+
+	require.NoError(t, b.StorePod(podUID, alloc))
+	assert.Equal(t, 0, b.walEntries, "hitting walMaxEntries should compact and reset the WAL")
+
+	pra, err := b.Load()
+	require.NoError(t, err)
+	assert.Equal(t, alloc, pra[podUID])
+}
+
+func TestFileBackendRestoreWALReplaysAcrossRestart(t *testing.T) {
+	stateDir := t.TempDir()
+	podUID, alloc := testAllocation()
+
+	b1, err := newFileBackend(stateDir, "allocation-checkpoint", WALOptions{}, func() PodResourceAllocation { return nil })
+	require.NoError(t, err)
+	require.NoError(t, b1.StorePod(podUID, alloc))
+	// Deliberately skip b1.Close() to simulate a crash that leaves the WAL
+	// unreset: restoreWAL on the next open must still recover the record.
+
+	b2, err := newFileBackend(stateDir, "allocation-checkpoint", WALOptions{}, func() PodResourceAllocation { return nil })
+	require.NoError(t, err)
+	defer b2.Close()
+
+	pra, err := b2.Load()
+	require.NoError(t, err)
+	assert.Equal(t, alloc, pra[podUID])
+}
+
+func TestReplayWALToleratesTruncatedTailRecord(t *testing.T) {
+	podUID, alloc := testAllocation()
+	walPath := path.Join(t.TempDir(), "allocation-checkpoint.wal")
+
+	f, err := os.OpenFile(walPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = writeWALRecord(f, walRecord{Op: walOpSet, PodUID: podUID, Containers: alloc})
+	require.NoError(t, err)
+	// Append a truncated record: a length prefix claiming more payload than
+	// actually follows it, as a crash mid-append would leave behind.
+	_, err = f.Write([]byte{0, 0, 0, 10, 'a', 'b'})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	pra, replayed, err := replayWAL(walPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, alloc, pra[podUID])
+}
+
+func TestReplayWALStopsAtCorruptMidJournalRecord(t *testing.T) {
+	podUID, alloc := testAllocation()
+	walPath := path.Join(t.TempDir(), "allocation-checkpoint.wal")
+
+	var buf bytes.Buffer
+	_, err := writeWALRecord(&buf, walRecord{Op: walOpSet, PodUID: podUID, Containers: alloc})
+	require.NoError(t, err)
+	_, err = writeWALRecord(&buf, walRecord{Op: walOpSet, PodUID: "pod-corrupt", Containers: alloc})
+	require.NoError(t, err)
+
+	// A record whose checksum doesn't match its payload, followed by another
+	// well-formed record: this is corruption with more of the journal still
+	// ahead of it, not just an incomplete final write.
+	_, err = writeWALRecord(&buf, walRecord{Op: walOpSet, PodUID: "pod-corrupt-checksum"})
+	require.NoError(t, err)
+	corruptEnd := buf.Len()
+	_, err = writeWALRecord(&buf, walRecord{Op: walOpSet, PodUID: "pod-after", Containers: alloc})
+	require.NoError(t, err)
+
+	data := buf.Bytes()
+	data[corruptEnd-1] ^= 0xFF // flip the last byte of the corrupt record's checksum trailer
+	require.NoError(t, os.WriteFile(walPath, data, 0600))
+
+	pra, replayed, err := replayWAL(walPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, replayed, "replay should stop at the corrupt record and not apply pod-after")
+	assert.Equal(t, alloc, pra[podUID])
+	assert.Contains(t, pra, types.UID("pod-corrupt"))
+	assert.NotContains(t, pra, types.UID("pod-after"))
+}
+
+func TestReadWALRecordRejectsOversizedLengthPrefix(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+
+	var lenBuf [4]byte
+	// Claim a payload far larger than maxWALRecordBytes; readWALRecord must
+	// reject this before ever calling make() with it.
+	lenBuf[0] = 0xFF
+	lenBuf[1] = 0xFF
+	lenBuf[2] = 0xFF
+	lenBuf[3] = 0xFF
+	_, err = w.Write(lenBuf[:])
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = readWALRecord(r)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds maximum")
+}
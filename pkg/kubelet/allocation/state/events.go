@@ -0,0 +1,121 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"context"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// AllocationOp identifies the mutation that produced an AllocationEvent.
+type AllocationOp string
+
+const (
+	// AllocationOpSet is emitted when a container's allocation is created or updated.
+	AllocationOpSet AllocationOp = "Set"
+	// AllocationOpDelete is emitted when a container's allocation is removed.
+	AllocationOpDelete AllocationOp = "Delete"
+)
+
+// AllocationEvent describes a single container allocation change. It is
+// always delivered after the change has been durably persisted, so a
+// subscriber never observes an allocation that a crash would roll back.
+type AllocationEvent struct {
+	PodUID        types.UID
+	ContainerName string
+	Old           v1.ResourceRequirements
+	New           v1.ResourceRequirements
+	Op            AllocationOp
+}
+
+// allocationEventBufferSize bounds the per-subscriber ring buffer. Once full,
+// publish drops the oldest queued event to make room for the newest one,
+// trading history for freshness.
+const allocationEventBufferSize = 64
+
+var droppedAllocationEvents = metrics.NewCounter(&metrics.CounterOpts{
+	Subsystem:      "node_allocation",
+	Name:           "dropped_events_total",
+	Help:           "Number of pod resource allocation change events dropped because a subscriber's buffer was full.",
+	StabilityLevel: metrics.ALPHA,
+})
+
+func init() {
+	legacyregistry.MustRegister(droppedAllocationEvents)
+}
+
+// allocationBroadcaster fans AllocationEvents out to subscribers, each with
+// its own bounded, drop-oldest buffer.
+type allocationBroadcaster struct {
+	mux         sync.Mutex
+	subscribers []chan AllocationEvent
+}
+
+// subscribe registers a new subscriber and returns its event channel. The
+// channel is closed once ctx is done.
+func (b *allocationBroadcaster) subscribe(ctx context.Context) <-chan AllocationEvent {
+	ch := make(chan AllocationEvent, allocationEventBufferSize)
+
+	b.mux.Lock()
+	b.subscribers = append(b.subscribers, ch)
+	b.mux.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mux.Lock()
+		defer b.mux.Unlock()
+		for i, c := range b.subscribers {
+			if c == ch {
+				b.subscribers = append(b.subscribers[:i], b.subscribers[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+
+	return ch
+}
+
+// publish delivers ev to every subscriber, dropping the oldest buffered
+// event for any subscriber whose buffer is full.
+func (b *allocationBroadcaster) publish(ev AllocationEvent) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- ev:
+			continue
+		default:
+		}
+		// Buffer full: drop the oldest event to make room, so subscribers
+		// stay current rather than stuck replaying stale history.
+		select {
+		case <-ch:
+			droppedAllocationEvents.Inc()
+		default:
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import "fmt"
+
+// PodResourceAllocationBackendFlag is the name of the kubelet flag that
+// selects the persistence Backend NewStateCheckpoint uses
+// (--pod-resource-allocation-backend={file,bolt}). cmd/kubelet/app/options
+// binds a string flag under this name and passes the value through
+// ParseBackendType to get the BackendType argument for NewStateCheckpoint.
+const PodResourceAllocationBackendFlag = "pod-resource-allocation-backend"
+
+// ParseBackendType validates the value of the --pod-resource-allocation-backend
+// flag and converts it to a BackendType. The empty string is accepted and
+// maps to BackendTypeFile, matching NewStateCheckpoint's own default, so an
+// unset flag behaves exactly as it did before the flag existed.
+func ParseBackendType(value string) (BackendType, error) {
+	switch BackendType(value) {
+	case "":
+		return BackendTypeFile, nil
+	case BackendTypeFile, BackendTypeBolt:
+		return BackendType(value), nil
+	default:
+		return "", fmt.Errorf("invalid value %q for --%s, must be one of: %s, %s",
+			value, PodResourceAllocationBackendFlag, BackendTypeFile, BackendTypeBolt)
+	}
+}
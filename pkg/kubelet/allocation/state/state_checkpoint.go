@@ -17,92 +17,63 @@ limitations under the License.
 package state
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
+	"hash/crc32"
+	"io"
 	"path"
+	"reflect"
 	"sync"
 
 	v1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
-	"k8s.io/klog/v2"
-	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager"
-	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager/checksum"
-	"k8s.io/kubernetes/pkg/kubelet/checkpointmanager/errors"
 )
 
 var _ State = &stateCheckpoint{}
 
 type stateCheckpoint struct {
-	mux               sync.RWMutex
-	cache             State
-	checkpointManager checkpointmanager.CheckpointManager
-	checkpointName    string
-	lastChecksum      checksum.Checksum
+	mux     sync.RWMutex
+	cache   State
+	backend Backend
+
+	broadcaster allocationBroadcaster
 }
 
-// NewStateCheckpoint creates new State for keeping track of pod resource allocations with checkpoint backend
-func NewStateCheckpoint(stateDir, checkpointName string) (State, error) {
-	checkpointManager, err := checkpointmanager.NewCheckpointManager(stateDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize checkpoint manager for pod allocation tracking: %v", err)
-	}
+// NewStateCheckpoint creates new State for keeping track of pod resource
+// allocations with checkpoint backend. backendType selects the persistence
+// implementation (see BackendType); the empty string defaults to
+// BackendTypeFile for backwards compatibility. walOptions configures the
+// delta journal compaction threshold used by BackendTypeFile; its zero value
+// selects the package defaults.
+func NewStateCheckpoint(stateDir, checkpointName string, backendType BackendType, walOptions WALOptions) (State, error) {
+	sc := &stateCheckpoint{}
 
-	pra, checksum, err := restoreState(checkpointManager, checkpointName)
+	backend, err := newBackend(backendType, stateDir, checkpointName, walOptions, func() PodResourceAllocation {
+		sc.mux.RLock()
+		defer sc.mux.RUnlock()
+		return sc.cache.GetPodResourceAllocation()
+	})
 	if err != nil {
 		//lint:ignore ST1005 user-facing error message
-		return nil, fmt.Errorf("could not restore state from checkpoint: %w, please drain this node and delete pod allocation checkpoint file %q before restarting Kubelet",
+		return nil, fmt.Errorf("could not restore state from checkpoint: %w, please drain this node and delete pod allocation checkpoint files for %q before restarting Kubelet",
 			err, path.Join(stateDir, checkpointName))
 	}
 
-	stateCheckpoint := &stateCheckpoint{
-		cache:             NewStateMemory(pra),
-		checkpointManager: checkpointManager,
-		checkpointName:    checkpointName,
-		lastChecksum:      checksum,
-	}
-	return stateCheckpoint, nil
-}
-
-// restores state from a checkpoint and creates it if it doesn't exist
-func restoreState(checkpointManager checkpointmanager.CheckpointManager, checkpointName string) (PodResourceAllocation, checksum.Checksum, error) {
-	checkpoint := &Checkpoint{}
-	if err := checkpointManager.GetCheckpoint(checkpointName, checkpoint); err != nil {
-		if err == errors.ErrCheckpointNotFound {
-			return nil, 0, nil
-		}
-		return nil, 0, err
-	}
-
-	praInfo, err := checkpoint.GetPodResourceAllocationInfo()
+	pra, err := backend.Load()
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get pod resource allocation info: %w", err)
+		//lint:ignore ST1005 user-facing error message
+		return nil, fmt.Errorf("could not restore state from checkpoint: %w, please drain this node and delete pod allocation checkpoint files for %q before restarting Kubelet",
+			err, path.Join(stateDir, checkpointName))
 	}
 
-	klog.V(2).InfoS("State checkpoint: restored pod resource allocation state from checkpoint")
-	return praInfo.AllocationEntries, checkpoint.Checksum, nil
-}
-
-// saves state to a checkpoint, caller is responsible for locking
-func (sc *stateCheckpoint) storeState() error {
-	podAllocation := sc.cache.GetPodResourceAllocation()
-
-	checkpoint, err := NewCheckpoint(&PodResourceAllocationInfo{
-		AllocationEntries: podAllocation,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create checkpoint: %w", err)
-	}
-	if checkpoint.Checksum == sc.lastChecksum {
-		// No changes to the checkpoint => no need to re-write it.
-		return nil
-	}
-	err = sc.checkpointManager.CreateCheckpoint(sc.checkpointName, checkpoint)
-	if err != nil {
-		klog.ErrorS(err, "Failed to save pod allocation checkpoint")
-		return err
-	}
-	sc.lastChecksum = checkpoint.Checksum
-	return nil
+	sc.cache = NewStateMemory(pra)
+	sc.backend = backend
+	return sc, nil
 }
 
 // GetContainerResourceAllocation returns current resources allocated to a pod's container
@@ -123,29 +94,72 @@ func (sc *stateCheckpoint) GetPodResourceAllocation() PodResourceAllocation {
 func (sc *stateCheckpoint) SetContainerResourceAllocation(podUID types.UID, containerName string, alloc v1.ResourceRequirements) error {
 	sc.mux.Lock()
 	defer sc.mux.Unlock()
+	old, _ := sc.cache.GetContainerResourceAllocation(podUID, containerName)
 	sc.cache.SetContainerResourceAllocation(podUID, containerName, alloc)
-	return sc.storeState()
+	if err := sc.backend.StorePod(podUID, sc.cache.GetPodResourceAllocation()[podUID]); err != nil {
+		return err
+	}
+	sc.broadcaster.publish(AllocationEvent{PodUID: podUID, ContainerName: containerName, Old: old, New: alloc, Op: AllocationOpSet})
+	return nil
 }
 
 // SetPodResourceAllocation sets pod resource allocation
 func (sc *stateCheckpoint) SetPodResourceAllocation(podUID types.UID, alloc map[string]v1.ResourceRequirements) error {
 	sc.mux.Lock()
 	defer sc.mux.Unlock()
-	err := sc.cache.SetPodResourceAllocation(podUID, alloc)
-	if err != nil {
+	oldPod := sc.cache.GetPodResourceAllocation()[podUID]
+	if err := sc.cache.SetPodResourceAllocation(podUID, alloc); err != nil {
+		return err
+	}
+	if err := sc.backend.StorePod(podUID, alloc); err != nil {
 		return err
 	}
-	return sc.storeState()
+	for containerName, newAlloc := range alloc {
+		sc.broadcaster.publish(AllocationEvent{PodUID: podUID, ContainerName: containerName, Old: oldPod[containerName], New: newAlloc, Op: AllocationOpSet})
+	}
+	for containerName, old := range oldPod {
+		if _, stillPresent := alloc[containerName]; !stillPresent {
+			sc.broadcaster.publish(AllocationEvent{PodUID: podUID, ContainerName: containerName, Old: old, Op: AllocationOpDelete})
+		}
+	}
+	return nil
 }
 
 // Delete deletes allocations for specified pod
 func (sc *stateCheckpoint) Delete(podUID types.UID, containerName string) error {
 	sc.mux.Lock()
 	defer sc.mux.Unlock()
-	// Skip writing the checkpoint for pod deletion, since there is no side effect to
-	// keeping a deleted pod. Deleted pods will eventually be cleaned up by RemoveOrphanedPods.
-	// The deletion will be stored the next time a non-delete update is made.
-	return sc.cache.Delete(podUID, "")
+	oldPod := sc.cache.GetPodResourceAllocation()[podUID]
+	if err := sc.cache.Delete(podUID, ""); err != nil {
+		return err
+	}
+	if err := sc.backend.DeletePod(podUID); err != nil {
+		return err
+	}
+	for cName, old := range oldPod {
+		sc.broadcaster.publish(AllocationEvent{PodUID: podUID, ContainerName: cName, Old: old, Op: AllocationOpDelete})
+	}
+	return nil
+}
+
+// Subscribe returns a channel of AllocationEvents for every container
+// allocation change, delivered after the change is durably persisted,
+// including changes made by bulk operations like ClearState and Import (both
+// diff the old and new allocation and publish one event per affected
+// container, the same as a Set or Delete would). The channel has a bounded
+// buffer; if a subscriber falls behind, the oldest buffered event is dropped
+// to make room for the newest one, and the node_allocation_dropped_events_total
+// metric is incremented. The channel is closed when ctx is done.
+func (sc *stateCheckpoint) Subscribe(ctx context.Context) <-chan AllocationEvent {
+	return sc.broadcaster.subscribe(ctx)
+}
+
+// Close releases the resources held by the underlying Backend (open file
+// handles, database locks). Callers that construct a stateCheckpoint outside
+// of the kubelet's own long-lived instance must call Close when they're done
+// with it.
+func (sc *stateCheckpoint) Close() error {
+	return sc.backend.Close()
 }
 
 func (sc *stateCheckpoint) RemoveOrphanedPods(remainingPods sets.Set[types.UID]) {
@@ -154,6 +168,358 @@ func (sc *stateCheckpoint) RemoveOrphanedPods(remainingPods sets.Set[types.UID])
 	// the orphaned pods will be removed the next time this method is called.
 }
 
+// ClearState clears the state and persists an empty checkpoint, discarding
+// any pending delta journal. This allows an operator to recover from a
+// corrupt-but-parseable checkpoint without deleting the file by hand.
+func (sc *stateCheckpoint) ClearState() error {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+	old := sc.cache.GetPodResourceAllocation()
+	sc.cache = NewStateMemory(nil)
+	if err := sc.backend.StoreAll(nil); err != nil {
+		return err
+	}
+	sc.publishDiff(old, nil)
+	return nil
+}
+
+// publishDiff publishes an AllocationEvent for every container whose
+// allocation differs between old and new, including containers present in
+// only one of the two. Callers that replace the entire allocation at once
+// (ClearState, Import) use this instead of the per-container publish calls
+// SetContainerResourceAllocation and SetPodResourceAllocation make, so
+// Subscribe's consumers still see every affected container rather than
+// going stale across a bulk operation.
+func (sc *stateCheckpoint) publishDiff(old, updated PodResourceAllocation) {
+	for podUID, oldPod := range old {
+		newPod := updated[podUID]
+		for containerName, oldAlloc := range oldPod {
+			if newAlloc, ok := newPod[containerName]; ok {
+				if !apiequality.Semantic.DeepEqual(oldAlloc, newAlloc) {
+					sc.broadcaster.publish(AllocationEvent{PodUID: podUID, ContainerName: containerName, Old: oldAlloc, New: newAlloc, Op: AllocationOpSet})
+				}
+				continue
+			}
+			sc.broadcaster.publish(AllocationEvent{PodUID: podUID, ContainerName: containerName, Old: oldAlloc, Op: AllocationOpDelete})
+		}
+	}
+	for podUID, newPod := range updated {
+		oldPod := old[podUID]
+		for containerName, newAlloc := range newPod {
+			if _, ok := oldPod[containerName]; ok {
+				continue // already handled above
+			}
+			sc.broadcaster.publish(AllocationEvent{PodUID: podUID, ContainerName: containerName, New: newAlloc, Op: AllocationOpSet})
+		}
+	}
+}
+
+// checkpointExportFormatVersion is bumped whenever the export bundle layout
+// changes in a way that isn't backwards compatible. Import rejects bundles
+// produced by an incompatible version rather than guessing at their layout.
+const checkpointExportFormatVersion = 1
+
+const (
+	exportEntryCheckpoint = "checkpoint.json"
+	exportEntryManifest   = "manifest.json"
+)
+
+// ImportMode controls how Import reconciles an export bundle with any
+// pre-existing state.
+type ImportMode int
+
+const (
+	// ImportModeReplaceAll discards the existing state entirely and replaces
+	// it with the contents of the imported bundle.
+	ImportModeReplaceAll ImportMode = iota
+	// ImportModeMergeSkipConflicts keeps the existing allocation for any pod
+	// UID that appears in both the existing state and the bundle.
+	ImportModeMergeSkipConflicts
+	// ImportModeMergeOverwrite takes the bundle's allocation for any pod UID
+	// that appears in both the existing state and the bundle.
+	ImportModeMergeOverwrite
+)
+
+// exportManifest accompanies the raw checkpoint JSON in an export bundle so
+// that tooling (and Import's version check) doesn't need to parse the
+// checkpoint itself just to know what's in it.
+type exportManifest struct {
+	FormatVersion int                 `json:"formatVersion"`
+	Pods          []exportManifestPod `json:"pods"`
+}
+
+type exportManifestPod struct {
+	PodUID     types.UID                          `json:"podUID"`
+	Containers map[string]v1.ResourceRequirements `json:"containers"`
+	// Checksum is crc32.ChecksumIEEE over the JSON encoding of Containers. It
+	// lets Import detect a manifest entry that was corrupted or edited
+	// independently of checkpoint.json, instead of trusting the manifest's
+	// contents on faith.
+	Checksum uint32 `json:"checksum"`
+}
+
+func checksumContainers(containers map[string]v1.ResourceRequirements) (uint32, error) {
+	data, err := json.Marshal(containers)
+	if err != nil {
+		return 0, err
+	}
+	return crc32.ChecksumIEEE(data), nil
+}
+
+// Export writes a versioned tar.gz bundle containing the current pod
+// resource allocation checkpoint to w. The bundle can be handed to Import on
+// another node (e.g. a replacement for a drained node) to rehydrate the same
+// allocations without racing the kubelet's own checkpoint file. The bundle
+// format is independent of which Backend produced it.
+func (sc *stateCheckpoint) Export(w io.Writer) error {
+	sc.mux.RLock()
+	defer sc.mux.RUnlock()
+	return writeExportBundle(w, sc.cache.GetPodResourceAllocation())
+}
+
+// writeExportBundle writes podAllocation as a versioned tar.gz bundle to w.
+// It never touches disk, so it's shared by Export (reading from a live
+// stateCheckpoint's cache) and ExportCheckpoint (reading a checkpoint
+// read-only from disk).
+func writeExportBundle(w io.Writer, podAllocation PodResourceAllocation) error {
+	checkpoint, err := NewCheckpoint(&PodResourceAllocationInfo{
+		AllocationEntries: podAllocation,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+	checkpointBytes, err := checkpoint.MarshalCheckpoint()
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	manifest := exportManifest{FormatVersion: checkpointExportFormatVersion}
+	for podUID, containers := range podAllocation {
+		sum, err := checksumContainers(containers)
+		if err != nil {
+			return fmt.Errorf("failed to checksum pod resource allocation for pod %q: %w", podUID, err)
+		}
+		manifest.Pods = append(manifest.Pods, exportManifestPod{PodUID: podUID, Containers: containers, Checksum: sum})
+	}
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal export manifest: %w", err)
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	if err := writeTarEntry(tw, exportEntryCheckpoint, checkpointBytes); err != nil {
+		return err
+	}
+	if err := writeTarEntry(tw, exportEntryManifest, manifestBytes); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize pod resource allocation export: %w", err)
+	}
+	return gw.Close()
+}
+
+func writeTarEntry(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data))}); err != nil {
+		return fmt.Errorf("failed to write tar header for %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write tar entry %s: %w", name, err)
+	}
+	return nil
+}
+
+// verifyManifest checks manifest against its own per-entry checksums and
+// cross-checks it against imported, the checkpoint-verified allocation it's
+// supposed to describe. checkpoint.json's checksum (already verified by the
+// caller) only covers checkpoint.json itself; without this, a manifest.json
+// edited or truncated independently of checkpoint.json would be accepted
+// without anyone ever reading its contents.
+func verifyManifest(manifest exportManifest, imported PodResourceAllocation) error {
+	if len(manifest.Pods) != len(imported) {
+		return fmt.Errorf("manifest lists %d pods but checkpoint contains %d", len(manifest.Pods), len(imported))
+	}
+	for _, entry := range manifest.Pods {
+		sum, err := checksumContainers(entry.Containers)
+		if err != nil {
+			return fmt.Errorf("failed to checksum manifest entry for pod %q: %w", entry.PodUID, err)
+		}
+		if sum != entry.Checksum {
+			return fmt.Errorf("manifest entry for pod %q failed checksum validation", entry.PodUID)
+		}
+		containers, ok := imported[entry.PodUID]
+		if !ok {
+			return fmt.Errorf("manifest lists pod %q which is missing from the checkpoint", entry.PodUID)
+		}
+		if !reflect.DeepEqual(containers, entry.Containers) {
+			return fmt.Errorf("manifest entry for pod %q does not match the checkpoint", entry.PodUID)
+		}
+	}
+	return nil
+}
+
+// parseExportBundle reads a bundle produced by Export/writeExportBundle and
+// returns the allocation it contains. The checksum embedded in the
+// checkpoint entry is validated, the manifest is cross-checked against it
+// (see verifyManifest), and a version mismatch between the bundle and this
+// kubelet is rejected outright. It never touches disk.
+func parseExportBundle(r io.Reader) (PodResourceAllocation, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pod resource allocation export: %w", err)
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+
+	var checkpointBytes, manifestBytes []byte
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pod resource allocation export: %w", err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pod resource allocation export entry %s: %w", hdr.Name, err)
+		}
+		switch hdr.Name {
+		case exportEntryCheckpoint:
+			checkpointBytes = body
+		case exportEntryManifest:
+			manifestBytes = body
+		}
+	}
+	if checkpointBytes == nil || manifestBytes == nil {
+		return nil, fmt.Errorf("pod resource allocation export is missing required entries")
+	}
+
+	var manifest exportManifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse pod resource allocation export manifest: %w", err)
+	}
+	if manifest.FormatVersion != checkpointExportFormatVersion {
+		return nil, fmt.Errorf("unsupported pod resource allocation export format version %d, this kubelet supports version %d",
+			manifest.FormatVersion, checkpointExportFormatVersion)
+	}
+
+	checkpoint := &Checkpoint{}
+	if err := checkpoint.UnmarshalCheckpoint(checkpointBytes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pod resource allocation export: %w", err)
+	}
+	if err := checkpoint.VerifyChecksum(); err != nil {
+		return nil, fmt.Errorf("pod resource allocation export failed checksum validation: %w", err)
+	}
+	praInfo, err := checkpoint.GetPodResourceAllocationInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod resource allocation info from export: %w", err)
+	}
+	imported := praInfo.AllocationEntries
+
+	if err := verifyManifest(manifest, imported); err != nil {
+		return nil, fmt.Errorf("pod resource allocation export manifest failed validation: %w", err)
+	}
+	return imported, nil
+}
+
+// reconcileImport combines existing with imported according to mode.
+// existing is ignored for ImportModeReplaceAll, so callers that only need
+// that mode don't have to load it first.
+func reconcileImport(existing, imported PodResourceAllocation, mode ImportMode) (PodResourceAllocation, error) {
+	switch mode {
+	case ImportModeReplaceAll:
+		return imported, nil
+	case ImportModeMergeSkipConflicts, ImportModeMergeOverwrite:
+		result := make(PodResourceAllocation, len(existing))
+		for podUID, containers := range existing {
+			result[podUID] = containers
+		}
+		for podUID, containers := range imported {
+			if _, conflict := result[podUID]; conflict && mode == ImportModeMergeSkipConflicts {
+				continue
+			}
+			result[podUID] = containers
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unknown pod resource allocation import mode %d", mode)
+	}
+}
+
+// Import reads a bundle produced by Export and reconciles it with the
+// current state according to mode. The checksum embedded in the checkpoint
+// entry is validated before anything is applied, and a version mismatch
+// between the bundle and this kubelet is rejected outright.
+func (sc *stateCheckpoint) Import(r io.Reader, mode ImportMode) error {
+	sc.mux.Lock()
+	defer sc.mux.Unlock()
+
+	imported, err := parseExportBundle(r)
+	if err != nil {
+		return err
+	}
+	old := sc.cache.GetPodResourceAllocation()
+	result, err := reconcileImport(old, imported, mode)
+	if err != nil {
+		return err
+	}
+
+	sc.cache = NewStateMemory(result)
+	if err := sc.backend.StoreAll(result); err != nil {
+		return err
+	}
+	sc.publishDiff(old, result)
+	return nil
+}
+
+// ExportCheckpoint is a CLI-friendly entrypoint for operator tooling: it
+// reads the pod resource allocation checkpoint from stateDir and writes an
+// export bundle to w, without requiring a running kubelet. Unlike
+// ImportCheckpoint, this never creates, rewrites, or removes anything on
+// disk, so it's safe to run against a checkpoint a live kubelet still owns.
+func ExportCheckpoint(stateDir, checkpointName string, backendType BackendType, w io.Writer) error {
+	podAllocation, err := loadAllocationReadOnly(backendType, stateDir, checkpointName)
+	if err != nil {
+		return err
+	}
+	return writeExportBundle(w, podAllocation)
+}
+
+// ImportCheckpoint is a CLI-friendly entrypoint for operator tooling: it
+// imports the bundle read from r into the pod resource allocation checkpoint
+// under stateDir using mode, without requiring a running kubelet. It reads
+// any existing state needed for a merge mode read-only, and only opens a
+// writable Backend for the final write. Unlike ExportCheckpoint, that final
+// write does mutate the on-disk checkpoint, so this must not be run against
+// a stateDir a live kubelet is also using.
+func ImportCheckpoint(stateDir, checkpointName string, backendType BackendType, r io.Reader, mode ImportMode) error {
+	imported, err := parseExportBundle(r)
+	if err != nil {
+		return err
+	}
+
+	var existing PodResourceAllocation
+	if mode != ImportModeReplaceAll {
+		existing, err = loadAllocationReadOnly(backendType, stateDir, checkpointName)
+		if err != nil {
+			return err
+		}
+	}
+	result, err := reconcileImport(existing, imported, mode)
+	if err != nil {
+		return err
+	}
+
+	backend, err := newBackend(backendType, stateDir, checkpointName, WALOptions{}, func() PodResourceAllocation { return result })
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+	return backend.StoreAll(result)
+}
+
 type noopStateCheckpoint struct{}
 
 // NewNoopStateCheckpoint creates a dummy state checkpoint manager
@@ -182,3 +548,28 @@ func (sc *noopStateCheckpoint) Delete(_ types.UID, _ string) error {
 }
 
 func (sc *noopStateCheckpoint) RemoveOrphanedPods(_ sets.Set[types.UID]) {}
+
+func (sc *noopStateCheckpoint) ClearState() error {
+	return nil
+}
+
+func (sc *noopStateCheckpoint) Close() error {
+	return nil
+}
+
+func (sc *noopStateCheckpoint) Export(_ io.Writer) error {
+	return nil
+}
+
+func (sc *noopStateCheckpoint) Import(_ io.Reader, _ ImportMode) error {
+	return nil
+}
+
+func (sc *noopStateCheckpoint) Subscribe(ctx context.Context) <-chan AllocationEvent {
+	ch := make(chan AllocationEvent)
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch
+}
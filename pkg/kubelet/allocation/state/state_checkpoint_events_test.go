@@ -0,0 +1,74 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func recvEvent(t *testing.T, ch <-chan AllocationEvent) AllocationEvent {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for AllocationEvent")
+		return AllocationEvent{}
+	}
+}
+
+func TestClearStatePublishesDeleteEvents(t *testing.T) {
+	sc := newTestStateCheckpoint(t)
+	podUID, alloc := testAllocation()
+	require.NoError(t, sc.SetPodResourceAllocation(podUID, alloc))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := sc.Subscribe(ctx)
+
+	require.NoError(t, sc.ClearState())
+
+	ev := recvEvent(t, ch)
+	require.Equal(t, podUID, ev.PodUID)
+	require.Equal(t, AllocationOpDelete, ev.Op)
+	require.Empty(t, sc.GetPodResourceAllocation(), "ClearState should leave no pods behind, not just publish a delete event")
+}
+
+func TestImportPublishesEvents(t *testing.T) {
+	src := newTestStateCheckpoint(t)
+	podUID, alloc := testAllocation()
+	require.NoError(t, src.SetPodResourceAllocation(podUID, alloc))
+
+	var bundle bytes.Buffer
+	require.NoError(t, src.Export(&bundle))
+
+	dst := newTestStateCheckpoint(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := dst.Subscribe(ctx)
+
+	require.NoError(t, dst.Import(bytes.NewReader(bundle.Bytes()), ImportModeReplaceAll))
+
+	ev := recvEvent(t, ch)
+	require.Equal(t, podUID, ev.PodUID)
+	require.Equal(t, AllocationOpSet, ev.Op)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func testAllocation() (types.UID, map[string]v1.ResourceRequirements) {
+	return "pod-1", map[string]v1.ResourceRequirements{
+		"c1": {Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")}},
+	}
+}
+
+func newTestStateCheckpoint(t *testing.T) *stateCheckpoint {
+	t.Helper()
+	s, err := NewStateCheckpoint(t.TempDir(), "allocation-checkpoint", BackendTypeFile, WALOptions{})
+	require.NoError(t, err)
+	sc, ok := s.(*stateCheckpoint)
+	require.True(t, ok)
+	return sc
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := newTestStateCheckpoint(t)
+	podUID, alloc := testAllocation()
+	require.NoError(t, src.SetPodResourceAllocation(podUID, alloc))
+
+	var bundle bytes.Buffer
+	require.NoError(t, src.Export(&bundle))
+
+	dst := newTestStateCheckpoint(t)
+	require.NoError(t, dst.Import(bytes.NewReader(bundle.Bytes()), ImportModeReplaceAll))
+
+	assert.Equal(t, alloc, dst.GetPodResourceAllocation()[podUID])
+}
+
+func TestImportRejectsFormatVersionSkew(t *testing.T) {
+	src := newTestStateCheckpoint(t)
+	podUID, alloc := testAllocation()
+	require.NoError(t, src.SetPodResourceAllocation(podUID, alloc))
+
+	var bundle bytes.Buffer
+	require.NoError(t, src.Export(&bundle))
+
+	tampered := rewriteManifest(t, bundle.Bytes(), func(m *exportManifest) {
+		m.FormatVersion++
+	})
+
+	dst := newTestStateCheckpoint(t)
+	err := dst.Import(bytes.NewReader(tampered), ImportModeReplaceAll)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported")
+}
+
+func TestImportRejectsManifestEntryChecksumMismatch(t *testing.T) {
+	src := newTestStateCheckpoint(t)
+	podUID, alloc := testAllocation()
+	require.NoError(t, src.SetPodResourceAllocation(podUID, alloc))
+
+	var bundle bytes.Buffer
+	require.NoError(t, src.Export(&bundle))
+
+	// Edit a manifest entry's containers without recomputing its checksum or
+	// touching checkpoint.json, simulating a manifest.json that was corrupted
+	// or edited independently of the checkpoint it's meant to describe.
+	tampered := rewriteManifest(t, bundle.Bytes(), func(m *exportManifest) {
+		m.Pods[0].Containers["c1"] = v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("999m")},
+		}
+	})
+
+	dst := newTestStateCheckpoint(t)
+	err := dst.Import(bytes.NewReader(tampered), ImportModeReplaceAll)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum validation")
+}
+
+func TestImportRejectsManifestMissingPod(t *testing.T) {
+	src := newTestStateCheckpoint(t)
+	podUID, alloc := testAllocation()
+	require.NoError(t, src.SetPodResourceAllocation(podUID, alloc))
+
+	var bundle bytes.Buffer
+	require.NoError(t, src.Export(&bundle))
+
+	tampered := rewriteManifest(t, bundle.Bytes(), func(m *exportManifest) {
+		m.Pods = nil
+	})
+
+	dst := newTestStateCheckpoint(t)
+	err := dst.Import(bytes.NewReader(tampered), ImportModeReplaceAll)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "manifest")
+}
+
+// rewriteManifest unpacks bundle, applies edit to its manifest.json entry,
+// and repacks checkpoint.json unchanged alongside the edited manifest. This
+// lets tests simulate a manifest.json that was corrupted or tampered with
+// independently of checkpoint.json.
+func rewriteManifest(t *testing.T, bundle []byte, edit func(*exportManifest)) []byte {
+	t.Helper()
+
+	gr, err := gzip.NewReader(bytes.NewReader(bundle))
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+
+	var checkpointBytes, manifestBytes []byte
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		switch hdr.Name {
+		case exportEntryCheckpoint:
+			checkpointBytes = body
+		case exportEntryManifest:
+			manifestBytes = body
+		}
+	}
+	require.NoError(t, gr.Close())
+
+	var manifest exportManifest
+	require.NoError(t, json.Unmarshal(manifestBytes, &manifest))
+	edit(&manifest)
+	editedManifestBytes, err := json.Marshal(manifest)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	require.NoError(t, writeTarEntry(tw, exportEntryCheckpoint, checkpointBytes))
+	require.NoError(t, writeTarEntry(tw, exportEntryManifest, editedManifestBytes))
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	return out.Bytes()
+}